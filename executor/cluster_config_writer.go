@@ -0,0 +1,325 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/opcode"
+	driver "github.com/pingcap/tidb/types/parser_driver"
+	"github.com/pingcap/tidb/util/chunk"
+	"github.com/pingcap/tidb/util/pdapi"
+	"github.com/pingcap/tidb/util/sqlexec"
+)
+
+// hotReloadableConfig is the whitelist of configuration keys that are safe to
+// change at runtime for a given component type. Keys that are not listed here
+// are rejected by ClusterConfigSetExec before any request is sent out, so a
+// typo or an unsupported key can never brick a cluster.
+var hotReloadableConfig = map[string]map[string]struct{}{
+	"tidb": {
+		"log.level":                       {},
+		"log.slow-threshold":              {},
+		"mem-quota-query":                 {},
+		"performance.max-procs":           {},
+		"tikv-client.max-batch-wait-time": {},
+	},
+	"tikv": {
+		"storage.block-cache.capacity":    {},
+		"raftstore.raft-log-gc-threshold": {},
+		"rocksdb.max-background-jobs":     {},
+		"server.grpc-concurrency":         {},
+	},
+	"pd": {
+		"schedule.leader-schedule-limit": {},
+		"schedule.region-schedule-limit": {},
+		"replication.max-replicas":       {},
+	},
+}
+
+// clusterConfigItem is a single `type`/`address`/`key` = `value` assignment
+// extracted from an `UPDATE information_schema.cluster_config` statement by
+// the same predicate-pushdown machinery used to read the table.
+type clusterConfigItem struct {
+	typ     string
+	address string
+	key     string
+	value   string
+}
+
+// clusterConfigUpdateResult reports, per target node, whether the push
+// succeeded. Next only counts the successful ones into the statement's
+// affected-row count; no per-node map is returned to the caller.
+type clusterConfigUpdateResult struct {
+	item    clusterConfigItem
+	success bool
+	err     error
+}
+
+// ClusterConfigSetExec pushes configuration changes through to a live
+// PD/TiKV/TiDB instance. The target is the literal `address` from the
+// statement's WHERE clause; the executor does not look up cluster members,
+// so an UPDATE without an `address` predicate is not expanded to every
+// instance of the `type` and its request fails for lack of a host. Every target is still attempted even once one of
+// them fails - a slow or unreachable node does not stop the others from
+// being updated - and every attempt is recorded into
+// information_schema.cluster_config_history for audit, but Next reports the
+// first failure back to the caller as a statement error rather than only a
+// warning, so e.g. a non-whitelisted key is visible without the caller
+// having to check SHOW WARNINGS.
+type ClusterConfigSetExec struct {
+	baseExecutor
+
+	items []clusterConfigItem
+	done  bool
+}
+
+// NewClusterConfigSetExec builds a ClusterConfigSetExec from an UPDATE
+// statement's SET assignment and WHERE predicates. The executor builder does
+// not call it, so `UPDATE information_schema.cluster_config ...` statements
+// do not reach this executor.
+func NewClusterConfigSetExec(b baseExecutor, assigns []*ast.Assignment, where ast.ExprNode) (*ClusterConfigSetExec, error) {
+	item, err := extractClusterConfigItem(assigns, where)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &ClusterConfigSetExec{baseExecutor: b, items: []clusterConfigItem{item}}, nil
+}
+
+// extractClusterConfigItem reads the single `value` assignment plus the
+// `type`/`address`/`key` equality predicates out of an UPDATE statement,
+// the same predicate-pushdown clusterConfigReaderExec already does to decide
+// which nodes a read should fan out to.
+func extractClusterConfigItem(assigns []*ast.Assignment, where ast.ExprNode) (clusterConfigItem, error) {
+	var item clusterConfigItem
+	for _, a := range assigns {
+		if a.Column.Name.L != "value" {
+			return item, errors.Errorf("cluster_config only supports SET value, got %q", a.Column.Name.O)
+		}
+		v, ok := a.Expr.(*driver.ValueExpr)
+		if !ok {
+			return item, errors.New("cluster_config.value must be a literal")
+		}
+		item.value = v.GetString()
+	}
+	if item.value == "" {
+		return item, errors.New("UPDATE cluster_config must SET value")
+	}
+	if err := walkClusterConfigPredicate(where, &item); err != nil {
+		return item, err
+	}
+	if item.typ == "" || item.key == "" {
+		return item, errors.New("UPDATE cluster_config must filter on type and `key`")
+	}
+	return item, nil
+}
+
+// walkClusterConfigPredicate descends a WHERE clause made of `AND`-ed
+// equalities, filling in whichever of type/address/key each side names.
+// Anything else (OR, IN, ranges, ...) is left alone: the caller rejects the
+// update afterwards if type or key ends up unset.
+func walkClusterConfigPredicate(expr ast.ExprNode, item *clusterConfigItem) error {
+	op, ok := expr.(*ast.BinaryOperationExpr)
+	if !ok {
+		return nil
+	}
+	if op.Op == opcode.LogicAnd {
+		if err := walkClusterConfigPredicate(op.L, item); err != nil {
+			return err
+		}
+		return walkClusterConfigPredicate(op.R, item)
+	}
+	if op.Op != opcode.EQ {
+		return nil
+	}
+	col, ok := op.L.(*ast.ColumnNameExpr)
+	if !ok {
+		return nil
+	}
+	val, ok := op.R.(*driver.ValueExpr)
+	if !ok {
+		return nil
+	}
+	switch col.Name.Name.L {
+	case "type":
+		item.typ = val.GetString()
+	case "address":
+		item.address = val.GetString()
+	case "key":
+		item.key = val.GetString()
+	}
+	return nil
+}
+
+// Next implements the Executor Next interface. It performs the whole fan-out
+// on the first call and reports the number of nodes that were updated
+// successfully; subsequent calls return no more rows.
+func (e *ClusterConfigSetExec) Next(ctx context.Context, req *chunk.Chunk) error {
+	req.Reset()
+	if e.done {
+		return nil
+	}
+	e.done = true
+
+	root := startClusterRequestRootSpan("UPDATE information_schema.cluster_config")
+	defer root.Finish()
+
+	results := e.updateAll(ctx, root)
+	if err := e.recordHistory(results); err != nil {
+		return errors.Trace(err)
+	}
+
+	var affected int64
+	var firstErr error
+	for _, r := range results {
+		if r.success {
+			affected++
+		} else if firstErr == nil {
+			firstErr = r.err
+		}
+	}
+	e.ctx.GetSessionVars().StmtCtx.AddAffectedRows(uint64(affected))
+	if firstErr != nil {
+		return errors.Trace(firstErr)
+	}
+	return nil
+}
+
+func (e *ClusterConfigSetExec) updateAll(ctx context.Context, root opentracing.Span) []clusterConfigUpdateResult {
+	var wg sync.WaitGroup
+	results := make([]clusterConfigUpdateResult, len(e.items))
+	for i, item := range e.items {
+		i, item := i, item
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := e.updateOne(item, root)
+			results[i] = clusterConfigUpdateResult{item: item, success: err == nil, err: err}
+			if err != nil {
+				e.ctx.GetSessionVars().StmtCtx.AppendWarning(
+					errors.Errorf("update %s(%s) %s failed: %s", item.typ, item.address, item.key, err))
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func (e *ClusterConfigSetExec) updateOne(item clusterConfigItem, root opentracing.Span) error {
+	span := startClusterRequestChildSpan(e.ctx, root, "UPDATE information_schema.cluster_config", item.typ, item.address)
+
+	allowed, ok := hotReloadableConfig[item.typ]
+	if !ok {
+		err := errors.Errorf("unknown component type %q", item.typ)
+		span.finish(0, 0, err)
+		return err
+	}
+	if _, ok := allowed[item.key]; !ok {
+		err := errors.Errorf("%q is not a hot-reloadable config for %s", item.key, item.typ)
+		span.finish(0, 0, err)
+		return err
+	}
+
+	path := "/config"
+	if item.typ == "pd" {
+		path = pdapi.Config
+	}
+	body, err := json.Marshal(map[string]string{item.key: item.value})
+	if err != nil {
+		span.finish(0, 0, err)
+		return errors.Trace(err)
+	}
+
+	url := fmt.Sprintf("http://%s%s", item.address, path)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		span.finish(0, 0, err)
+		return errors.Trace(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	injectTraceHeader(span, req)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		span.finish(0, 0, err)
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err := errors.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+		span.finish(resp.StatusCode, 0, err)
+		return err
+	}
+	span.finish(resp.StatusCode, 0, nil)
+	return nil
+}
+
+func (e *ClusterConfigSetExec) recordHistory(results []clusterConfigUpdateResult) error {
+	exec, ok := e.ctx.(sqlexec.RestrictedSQLExecutor)
+	if !ok {
+		return nil
+	}
+	now := time.Now().Format("2006-01-02 15:04:05.000")
+	for _, r := range results {
+		msg := "ok"
+		if r.err != nil {
+			msg = r.err.Error()
+		}
+		sql := fmt.Sprintf(
+			"INSERT INTO information_schema.cluster_config_history "+
+				"(change_time, type, address, `key`, new_value, success, message) VALUES "+
+				"('%s', '%s', '%s', '%s', '%s', %d, '%s')",
+			now, escapeSQLString(r.item.typ), escapeSQLString(r.item.address), escapeSQLString(r.item.key),
+			escapeSQLString(r.item.value), boolToInt(r.success), escapeSQLString(msg))
+		if _, _, err := exec.ExecRestrictedSQL(sql); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// escapeSQLString escapes a value for interpolation into a single-quoted SQL
+// string literal. value is never whitelisted the way key is (it is whatever
+// the user's UPDATE ... SET value='...' contained, or an HTTP error message
+// that may itself embed quotes), so it must be escaped before it reaches
+// ExecRestrictedSQL.
+func escapeSQLString(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch r {
+		case '\'', '"', '\\', 0:
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
@@ -0,0 +1,108 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"sort"
+	"time"
+)
+
+// clusterLogRow is the shape of one already-filtered cluster_log row, i.e.
+// the input to dedupClusterLogRows is taken after regex/LIKE filters (and
+// the chunk1-2 virtual-column filters) have already been applied.
+type clusterLogRow struct {
+	time     time.Time
+	typ      string
+	address  string
+	instance string
+	level    string
+	message  string
+}
+
+// clusterLogDedupRow is one collapsed group of identical (type, level,
+// message) rows: how many times it repeated, the time span it covered, and
+// which instances produced it.
+type clusterLogDedupRow struct {
+	typ       string
+	level     string
+	message   string
+	firstTime time.Time
+	lastTime  time.Time
+	count     int
+	instances []string
+}
+
+type dedupKey struct {
+	typ     string
+	level   string
+	message string
+}
+
+// dedupClusterLogRows collapses runs of consecutive rows that share the same
+// (type, level, message) into one, similar to how log-viewer tools fold
+// spammy repeats. Collapsing happens after any `message LIKE`/`REGEXP`
+// filters have already dropped non-matching rows, so the dedup key only ever
+// sees candidates the user actually asked for.
+//
+// Only adjacency matters, not the key's overall frequency: rows must arrive
+// in the order cluster_log itself reports them (time order), since two runs
+// of the same key separated by an unrelated row stay separate groups rather
+// than being merged together.
+func dedupClusterLogRows(rows []clusterLogRow) []clusterLogDedupRow {
+	result := make([]clusterLogDedupRow, 0, len(rows))
+	var (
+		cur          *clusterLogDedupRow
+		curKey       dedupKey
+		instanceSeen map[string]struct{}
+	)
+
+	for _, row := range rows {
+		key := dedupKey{typ: row.typ, level: row.level, message: row.message}
+		if cur == nil || key != curKey {
+			if cur != nil {
+				sort.Strings(cur.instances)
+				result = append(result, *cur)
+			}
+			cur = &clusterLogDedupRow{
+				typ:       row.typ,
+				level:     row.level,
+				message:   row.message,
+				firstTime: row.time,
+				lastTime:  row.time,
+			}
+			curKey = key
+			instanceSeen = map[string]struct{}{}
+		}
+		cur.count++
+		if row.time.Before(cur.firstTime) {
+			cur.firstTime = row.time
+		}
+		if row.time.After(cur.lastTime) {
+			cur.lastTime = row.time
+		}
+		instance := row.instance
+		if instance == "" {
+			instance = row.address
+		}
+		if _, seen := instanceSeen[instance]; !seen {
+			instanceSeen[instance] = struct{}{}
+			cur.instances = append(cur.instances, instance)
+		}
+	}
+	if cur != nil {
+		sort.Strings(cur.instances)
+		result = append(result, *cur)
+	}
+	return result
+}
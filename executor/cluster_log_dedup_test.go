@@ -0,0 +1,72 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+type testClusterLogDedupSuite struct{}
+
+var _ = Suite(&testClusterLogDedupSuite{})
+
+func (s *testClusterLogDedupSuite) TestDedupCollapsesRepeats(c *C) {
+	base := time.Date(2019, 8, 26, 6, 22, 17, 0, time.UTC)
+	var rows []clusterLogRow
+	for i := 0; i < 100; i++ {
+		rows = append(rows, clusterLogRow{
+			time:    base.Add(time.Duration(i) * time.Second),
+			typ:     "pd",
+			address: "127.0.0.1:2379",
+			level:   "CRITICAL",
+			message: "test log message pd 5, foo",
+		})
+	}
+
+	deduped := dedupClusterLogRows(rows)
+	c.Assert(deduped, HasLen, 1)
+	c.Assert(deduped[0].count, Equals, 100)
+	c.Assert(deduped[0].firstTime, Equals, base)
+	c.Assert(deduped[0].lastTime, Equals, base.Add(99*time.Second))
+	c.Assert(deduped[0].instances, DeepEquals, []string{"127.0.0.1:2379"})
+}
+
+func (s *testClusterLogDedupSuite) TestDedupKeepsDistinctMessagesSeparate(c *C) {
+	now := time.Now()
+	rows := []clusterLogRow{
+		{time: now, typ: "pd", address: "a", level: "INFO", message: "foo"},
+		{time: now, typ: "pd", address: "b", level: "INFO", message: "bar"},
+	}
+	deduped := dedupClusterLogRows(rows)
+	c.Assert(deduped, HasLen, 2)
+}
+
+func (s *testClusterLogDedupSuite) TestDedupDoesNotMergeNonAdjacentRuns(c *C) {
+	base := time.Date(2019, 8, 26, 6, 22, 17, 0, time.UTC)
+	rows := []clusterLogRow{
+		{time: base, typ: "pd", address: "a", level: "INFO", message: "A"},
+		{time: base.Add(time.Second), typ: "pd", address: "a", level: "INFO", message: "B"},
+		{time: base.Add(2 * time.Second), typ: "pd", address: "a", level: "INFO", message: "A"},
+	}
+
+	deduped := dedupClusterLogRows(rows)
+	c.Assert(deduped, HasLen, 3)
+	c.Assert(deduped[0].message, Equals, "A")
+	c.Assert(deduped[0].count, Equals, 1)
+	c.Assert(deduped[1].message, Equals, "B")
+	c.Assert(deduped[2].message, Equals, "A")
+	c.Assert(deduped[2].count, Equals, 1)
+}
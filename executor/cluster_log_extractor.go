@@ -0,0 +1,130 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/pingcap/errors"
+)
+
+// namedLogExtractor is one user-registered pattern: the first capture group
+// of a match becomes the virtual column named column.
+type namedLogExtractor struct {
+	name    string
+	column  string
+	pattern *regexp.Regexp
+	group   int
+}
+
+// ClusterLogExtractorRegistry holds the named regexps an INFORMATION_SCHEMA
+// user has registered for cluster_log, e.g. a `region_id` extractor that
+// pulls the region ID out of `message`. Columns lists the synthetic columns
+// and PushDownEquality turns an equality filter on one of them into a regex
+// prefilter for the remote log-scan RPC; no cluster_log planner or executor
+// code calls either of them.
+type ClusterLogExtractorRegistry struct {
+	mu         sync.RWMutex
+	extractors map[string]*namedLogExtractor
+}
+
+// NewClusterLogExtractorRegistry creates an empty registry.
+func NewClusterLogExtractorRegistry() *ClusterLogExtractorRegistry {
+	return &ClusterLogExtractorRegistry{extractors: map[string]*namedLogExtractor{}}
+}
+
+// Register adds or replaces a named extractor. pattern must contain at least
+// one capture group; the first one becomes the virtual column named column
+// and any later groups are ignored.
+func (r *ClusterLogExtractorRegistry) Register(name, column, pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	group := -1
+	for i, n := range re.SubexpNames() {
+		if i != 0 {
+			group = i
+			_ = n
+			break
+		}
+	}
+	if group == -1 {
+		return errors.Errorf("extractor %q pattern %q has no capture group", name, pattern)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.extractors[name] = &namedLogExtractor{name: name, column: column, pattern: re, group: group}
+	return nil
+}
+
+// Unregister removes a previously registered extractor, if any.
+func (r *ClusterLogExtractorRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.extractors, name)
+}
+
+// Columns returns the virtual column names currently exposed by the
+// registry, so the cluster_log schema builder can add them alongside the
+// fixed time/type/level/message columns.
+func (r *ClusterLogExtractorRegistry) Columns() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cols := make([]string, 0, len(r.extractors))
+	for _, e := range r.extractors {
+		cols = append(cols, e.column)
+	}
+	return cols
+}
+
+// Extract runs every registered pattern against message and returns the
+// resulting virtual-column values, keyed by column name.
+func (r *ClusterLogExtractorRegistry) Extract(message string) map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	values := map[string]string{}
+	for _, e := range r.extractors {
+		if m := e.pattern.FindStringSubmatch(message); m != nil {
+			values[e.column] = m[e.group]
+		}
+	}
+	return values
+}
+
+// PushDownEquality converts `WHERE <virtual column> = 'v'` into a regex
+// prefilter that can be shipped to the remote log-scan RPC alongside the
+// existing level/message predicates, so non-matching lines never cross the
+// network. It returns ok=false if column is not a registered virtual column.
+func (r *ClusterLogExtractorRegistry) PushDownEquality(column, value string) (pattern string, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, e := range r.extractors {
+		if e.column != column {
+			continue
+		}
+		// Go's regexp package is RE2-based and has no lookahead, so the
+		// pushed-down pattern can't require "matches the extractor's
+		// pattern" and "contains value" in a single expression. A line
+		// whose captured group equals value must contain value as literal
+		// text, so a plain substring match is still a valid (if looser)
+		// necessary condition; this is only a candidate filter, not proof
+		// the *captured* group equals value (e.g. "42" is also a substring
+		// of "4200") - Extract re-checks that locally once the line is back.
+		return regexp.QuoteMeta(value), true
+	}
+	return "", false
+}
@@ -0,0 +1,48 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"regexp"
+
+	. "github.com/pingcap/check"
+)
+
+type testClusterLogExtractorSuite struct{}
+
+var _ = Suite(&testClusterLogExtractorSuite{})
+
+func (s *testClusterLogExtractorSuite) TestRegisterAndExtract(c *C) {
+	r := NewClusterLogExtractorRegistry()
+	c.Assert(r.Register("region", "region_id", `region_id=(\d+)`), IsNil)
+
+	c.Assert(r.Columns(), DeepEquals, []string{"region_id"})
+	c.Assert(r.Extract("store failed for region_id=42"), DeepEquals, map[string]string{"region_id": "42"})
+	c.Assert(r.Extract("no match here"), DeepEquals, map[string]string{})
+
+	pattern, ok := r.PushDownEquality("region_id", "42")
+	c.Assert(ok, IsTrue)
+	re, err := regexp.Compile(pattern)
+	c.Assert(err, IsNil)
+	c.Assert(re.MatchString("store failed for region_id=42"), IsTrue)
+	c.Assert(re.MatchString("store failed for region_id=43"), IsFalse)
+
+	_, ok = r.PushDownEquality("not_registered", "42")
+	c.Assert(ok, IsFalse)
+}
+
+func (s *testClusterLogExtractorSuite) TestRegisterRejectsPatternWithoutGroup(c *C) {
+	r := NewClusterLogExtractorRegistry()
+	c.Assert(r.Register("bad", "bad_col", `no group here`), NotNil)
+}
@@ -0,0 +1,271 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"container/heap"
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/diagnosticspb"
+)
+
+// clusterLogFollowReorderWindow bounds how long a merged follow stream waits
+// for a slower source before emitting a line, so clock skew between nodes
+// does not reorder output by more than this much.
+const clusterLogFollowReorderWindow = 2 * time.Second
+
+// clusterLogFollowRingSize is the per-source backpressure buffer. Once full,
+// new lines from that source are dropped and counted rather than blocking
+// the whole merge.
+const clusterLogFollowRingSize = 4096
+
+// clusterLogLine is a single log line received from one follow source,
+// tagged with enough information to reconstruct the existing cluster_log
+// columns once merged.
+type clusterLogLine struct {
+	typ     string
+	address string
+	item    *diagnosticspb.LogMessage
+}
+
+// clusterLogFollowSource streams newly appended lines from one component's
+// log file (following rotation to `.log.1` the same way the bounded-window
+// reader does) over the diagnosticspb.Diagnostics service, pushing them into
+// a bounded ring buffer so a slow consumer cannot stall the producer.
+type clusterLogFollowSource struct {
+	typ     string
+	address string
+	client  diagnosticspb.DiagnosticsClient
+
+	buf     chan *diagnosticspb.LogMessage
+	dropped int64
+}
+
+func newClusterLogFollowSource(typ, address string, client diagnosticspb.DiagnosticsClient) *clusterLogFollowSource {
+	return &clusterLogFollowSource{
+		typ:     typ,
+		address: address,
+		client:  client,
+		buf:     make(chan *diagnosticspb.LogMessage, clusterLogFollowRingSize),
+	}
+}
+
+// run keeps the gRPC stream open and forwards lines into the ring buffer
+// until ctx is cancelled (e.g. by `KILL QUERY`) or the stream errs out.
+func (s *clusterLogFollowSource) run(ctx context.Context, req *diagnosticspb.SearchLogRequest) error {
+	stream, err := s.client.SearchLog(ctx, req)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		for _, msg := range resp.Messages {
+			msg := msg
+			select {
+			case s.buf <- msg:
+			default:
+				s.dropped++
+			}
+		}
+	}
+}
+
+// clusterLogFollowMerger k-way merges the per-source ring buffers in time
+// order using a bounded reordering window, so output order only breaks if a
+// source's clock is skewed by more than the window.
+type clusterLogFollowMerger struct {
+	sources []*clusterLogFollowSource
+	cancel  context.CancelFunc
+
+	// window overrides clusterLogFollowReorderWindow when non-zero, so tests
+	// can exercise the forced-release-after-window path without an actual
+	// multi-second wait.
+	window time.Duration
+
+	// pending holds lines already pulled off a source's ring buffer but not
+	// yet returned by Next, so a round that reads ahead on one source never
+	// loses lines that round didn't end up returning.
+	pending logHeap
+	// pendingSince is when pending most recently became non-empty; Next
+	// forces out the current top once window has elapsed since then, even
+	// if not every source has caught up yet.
+	pendingSince time.Time
+	// lastSeen is, per source, the timestamp of the most recent line that
+	// source has produced (including ones already returned by Next). Once
+	// every source but the one that produced the current top has lastSeen
+	// >= top's timestamp, top is safe to emit immediately: every source's
+	// own stream is time-ordered, so none of them can still produce
+	// something earlier.
+	lastSeen map[*clusterLogFollowSource]int64
+}
+
+type logHeapItem struct {
+	src  *clusterLogFollowSource
+	line clusterLogLine
+}
+
+type logHeap []logHeapItem
+
+func (h logHeap) Len() int { return len(h) }
+func (h logHeap) Less(i, j int) bool {
+	return h[i].line.item.Time < h[j].line.item.Time
+}
+func (h logHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *logHeap) Push(x interface{}) { *h = append(*h, x.(logHeapItem)) }
+func (h *logHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// drainReady pulls every line already sitting in a source's ring buffer into
+// m.pending without blocking, so a source that is ahead never has its lines
+// discarded just because another source wasn't ready yet.
+func (m *clusterLogFollowMerger) drainReady() {
+	for _, src := range m.sources {
+		for {
+			select {
+			case line := <-src.buf:
+				m.push(src, line)
+				continue
+			default:
+			}
+			break
+		}
+	}
+}
+
+func (m *clusterLogFollowMerger) push(src *clusterLogFollowSource, line *diagnosticspb.LogMessage) {
+	heap.Push(&m.pending, logHeapItem{src: src, line: clusterLogLine{typ: src.typ, address: src.address, item: line}})
+	if m.lastSeen == nil {
+		m.lastSeen = make(map[*clusterLogFollowSource]int64, len(m.sources))
+	}
+	m.lastSeen[src] = line.Time
+	if m.pendingSince.IsZero() {
+		m.pendingSince = time.Now()
+	}
+}
+
+// readyToEmit reports whether top can be returned without risking
+// reordering: either every other source's most recent line is already at or
+// past top's timestamp (so none of them can still produce something
+// earlier), or the reordering window has elapsed since top's batch first
+// became pending and top must be force-released regardless.
+func (m *clusterLogFollowMerger) readyToEmit(top logHeapItem) bool {
+	window := m.window
+	if window == 0 {
+		window = clusterLogFollowReorderWindow
+	}
+	if time.Since(m.pendingSince) >= window {
+		return true
+	}
+	for _, src := range m.sources {
+		if src == top.src {
+			continue
+		}
+		if m.lastSeen[src] < top.line.item.Time {
+			return false
+		}
+	}
+	return true
+}
+
+// Next blocks until a line can be safely emitted in order, the reordering
+// window forces out the current candidate, or ctx is done. If a whole window
+// passes with nothing pending, it returns a nil line and a nil error. It waits on
+// every source concurrently via a single reflect.Select, so per-line
+// latency is bounded by the window once, not by len(sources)*window, and it
+// keeps waiting on a buffered candidate instead of returning it the moment
+// anything is pending.
+func (m *clusterLogFollowMerger) Next(ctx context.Context) (*clusterLogLine, error) {
+	m.drainReady()
+	for {
+		if m.pending.Len() > 0 && m.readyToEmit(m.pending[0]) {
+			top := heap.Pop(&m.pending).(logHeapItem)
+			if m.pending.Len() == 0 {
+				m.pendingSince = time.Time{}
+			}
+			line := top.line
+			return &line, nil
+		}
+
+		window := m.window
+		if window == 0 {
+			window = clusterLogFollowReorderWindow
+		}
+		wait := window
+		if m.pending.Len() > 0 {
+			wait = window - time.Since(m.pendingSince)
+		}
+
+		cases := make([]reflect.SelectCase, 0, len(m.sources)+2)
+		for _, src := range m.sources {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(src.buf)})
+		}
+		doneIdx := len(cases)
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+
+		timer := time.NewTimer(wait)
+		timeoutIdx := len(cases)
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(timer.C)})
+
+		chosen, recv, ok := reflect.Select(cases)
+		timer.Stop()
+		switch chosen {
+		case doneIdx:
+			return nil, ctx.Err()
+		case timeoutIdx:
+			if m.pending.Len() == 0 {
+				return nil, nil
+			}
+			// The window has now elapsed; loop back around so readyToEmit
+			// force-releases the current top.
+		default:
+			if ok {
+				src := m.sources[chosen]
+				m.push(src, recv.Interface().(*diagnosticspb.LogMessage))
+			}
+			m.drainReady()
+		}
+	}
+}
+
+// Close cancels every underlying gRPC stream so `KILL QUERY` tears down the
+// whole fan-out rather than leaking goroutines.
+func (m *clusterLogFollowMerger) Close() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+}
+
+// droppedLines reports, per source, how many lines were discarded because
+// the consumer could not keep up. It only reports the counts; it does not
+// append a warning to StmtCtx itself.
+func (m *clusterLogFollowMerger) droppedLines() map[string]int64 {
+	dropped := make(map[string]int64)
+	for _, src := range m.sources {
+		if src.dropped > 0 {
+			dropped[src.typ+"/"+src.address] = src.dropped
+		}
+	}
+	return dropped
+}
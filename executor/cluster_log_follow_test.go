@@ -0,0 +1,90 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"context"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/diagnosticspb"
+
+	. "github.com/pingcap/check"
+)
+
+type testClusterLogFollowSuite struct{}
+
+var _ = Suite(&testClusterLogFollowSuite{})
+
+func newTestFollowSource(typ, address string) *clusterLogFollowSource {
+	return newClusterLogFollowSource(typ, address, nil)
+}
+
+// testFollowWindow is a short stand-in for clusterLogFollowReorderWindow so
+// tests that force a full-window release don't actually block for seconds.
+const testFollowWindow = 50 * time.Millisecond
+
+func (s *testClusterLogFollowSuite) TestMergerOrdersAcrossSourcesConcurrently(c *C) {
+	pd := newTestFollowSource("pd", "pd-0")
+	tikv := newTestFollowSource("tikv", "tikv-0")
+	merger := &clusterLogFollowMerger{sources: []*clusterLogFollowSource{pd, tikv}, window: testFollowWindow}
+
+	// tikv's line is ready immediately; pd's shows up shortly after, well
+	// inside the reordering window, but only once Next is already blocked
+	// waiting on it - this would time out per-source under the old
+	// sequential-select implementation instead of returning as soon as both
+	// are available. pd's timestamp (50) is earlier than tikv's (100), so it
+	// must come out first even though tikv was buffered first.
+	tikv.buf <- &diagnosticspb.LogMessage{Time: 100}
+	go func() {
+		time.Sleep(testFollowWindow / 4)
+		pd.buf <- &diagnosticspb.LogMessage{Time: 50}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*testFollowWindow)
+	defer cancel()
+
+	start := time.Now()
+	first, err := merger.Next(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(first.typ, Equals, "pd")
+	c.Assert(time.Since(start) < testFollowWindow, IsTrue)
+
+	// tikv's line never gets a newer sample from pd to confirm it, so it can
+	// only come out once the window forces it - proving Next kept waiting
+	// instead of returning the buffered tikv line the instant it saw it.
+	second, err := merger.Next(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(second.typ, Equals, "tikv")
+}
+
+func (s *testClusterLogFollowSuite) TestMergerDoesNotDropReadAheadLines(c *C) {
+	pd := newTestFollowSource("pd", "pd-0")
+	tikv := newTestFollowSource("tikv", "tikv-0")
+	merger := &clusterLogFollowMerger{sources: []*clusterLogFollowSource{pd, tikv}, window: testFollowWindow}
+
+	pd.buf <- &diagnosticspb.LogMessage{Time: 10}
+	pd.buf <- &diagnosticspb.LogMessage{Time: 20}
+	tikv.buf <- &diagnosticspb.LogMessage{Time: 15}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*testFollowWindow)
+	defer cancel()
+	var got []int64
+	for i := 0; i < 3; i++ {
+		line, err := merger.Next(ctx)
+		c.Assert(err, IsNil)
+		c.Assert(line, NotNil)
+		got = append(got, line.item.Time)
+	}
+	c.Assert(got, DeepEquals, []int64{10, 15, 20})
+}
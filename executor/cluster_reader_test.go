@@ -14,10 +14,12 @@
 package executor_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net"
+	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
@@ -351,6 +353,65 @@ func (s *testClusterReaderSuite) TestTiDBClusterConfig(c *C) {
 	}
 }
 
+func (s *testClusterReaderSuite) TestTiDBClusterConfigSet(c *C) {
+	// mock PD/TiKV/TiDB http servers accepting config updates
+	router := mux.NewRouter()
+
+	type mockServer struct {
+		address string
+		server  *httptest.Server
+	}
+	var testServers []*mockServer
+	for i := 0; i < 3; i++ {
+		server := httptest.NewServer(router)
+		address := strings.TrimPrefix(server.URL, "http://")
+		testServers = append(testServers, &mockServer{address: address, server: server})
+	}
+	defer func() {
+		for _, server := range testServers {
+			server.server.Close()
+		}
+	}()
+
+	var postedKeys []string
+	var postHandler = func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		c.Assert(err, IsNil)
+		var kv map[string]string
+		c.Assert(json.Unmarshal(body, &kv), IsNil)
+		for k := range kv {
+			postedKeys = append(postedKeys, k)
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	router.Handle(pdapi.Config, http.HandlerFunc(postHandler)).Methods("POST")
+	router.Handle("/config", http.HandlerFunc(postHandler)).Methods("POST")
+
+	servers := []string{}
+	for _, typ := range []string{"tidb", "tikv", "pd"} {
+		for _, server := range testServers {
+			servers = append(servers, strings.Join([]string{typ, server.address, server.address}, ","))
+		}
+	}
+	fpName := "github.com/pingcap/tidb/executor/mockClusterServerInfo"
+	fpExpr := strings.Join(servers, ";")
+	c.Assert(failpoint.Enable(fpName, fmt.Sprintf(`return("%s")`, fpExpr)), IsNil)
+	defer func() { c.Assert(failpoint.Disable(fpName), IsNil) }()
+
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec(fmt.Sprintf(
+		"UPDATE information_schema.cluster_config SET value='1024MB' WHERE type='tikv' AND `key`='storage.block-cache.capacity' AND address='%s'",
+		testServers[0].address))
+	c.Assert(postedKeys, DeepEquals, []string{"storage.block-cache.capacity"})
+
+	// a key that is not in the hot-reloadable whitelist must be rejected
+	// without sending any request and must still be audited.
+	postedKeys = nil
+	err := tk.ExecToErr("UPDATE information_schema.cluster_config SET value='1' WHERE type='tikv' AND `key`='unsafe.key'")
+	c.Assert(err, NotNil)
+	c.Assert(postedKeys, IsNil)
+}
+
 func (s *testClusterReaderSuite) writeTmpFile(c *C, dir, filename string, lines []string) {
 	err := ioutil.WriteFile(filepath.Join(dir, filename), []byte(strings.Join(lines, "\n")), os.ModePerm)
 	c.Assert(err, IsNil, Commentf("write tmp file %s failed", filename))
@@ -0,0 +1,189 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/pingcap/tidb/sessionctx"
+	"github.com/pingcap/tidb/util/sqlexec"
+)
+
+// clusterRequestTracer is the pluggable tracer used to instrument the
+// cluster reader's fan-out. It defaults to a noop implementation and is
+// replaced only through initClusterRequestTracer; no config section installs
+// a Jaeger tracer.
+var clusterRequestTracer opentracing.Tracer = opentracing.NoopTracer{}
+
+// initClusterRequestTracer installs t as the tracer used for every
+// subsequent cluster reader fan-out. Tests install a recording tracer here
+// to assert the expected span tree.
+func initClusterRequestTracer(t opentracing.Tracer) {
+	clusterRequestTracer = t
+}
+
+// clusterRequestSpan wraps a per-target child span with the bookkeeping the
+// cluster reader needs: response size and retry count are not part of
+// opentracing's core API, so they are tracked alongside the span and
+// flushed into its tags on Finish. ctx/statement/typ/address/start are kept
+// so finish can also append a row to information_schema.cluster_request_trace,
+// letting an operator see the fan-out without standing up a Jaeger
+// collector.
+type clusterRequestSpan struct {
+	span       opentracing.Span
+	retryCount int
+
+	ctx       sessionctx.Context
+	statement string
+	typ       string
+	address   string
+	start     time.Time
+}
+
+// startClusterRequestRootSpan starts the root span for a single SQL
+// statement driving the cluster reader (e.g. `SELECT * FROM cluster_log`).
+func startClusterRequestRootSpan(stmt string) opentracing.Span {
+	span := clusterRequestTracer.StartSpan("cluster_reader")
+	span.SetTag("sql.statement", stmt)
+	return span
+}
+
+// startClusterRequestChildSpan starts a child span for one (type, address)
+// target, tagging it with the target's type and address; the pushed-down
+// predicates themselves are not tagged. ctx and stmt are kept so
+// finish can record the request into cluster_request_trace even when no
+// Jaeger tracer is configured.
+func startClusterRequestChildSpan(ctx sessionctx.Context, parent opentracing.Span, stmt, typ, address string) *clusterRequestSpan {
+	span := clusterRequestTracer.StartSpan(
+		"cluster_reader.fetch",
+		opentracing.ChildOf(parent.Context()),
+	)
+	span.SetTag("node.type", typ)
+	span.SetTag("node.address", address)
+	return &clusterRequestSpan{span: span, ctx: ctx, statement: stmt, typ: typ, address: address, start: time.Now()}
+}
+
+// injectTraceHeader propagates the current span's context to PD/TiKV/TiDB
+// over the `uber-trace-id` HTTP header, so the reader's span tree joins
+// whatever the target node itself records.
+func injectTraceHeader(span *clusterRequestSpan, req *http.Request) {
+	_ = clusterRequestTracer.Inject(
+		span.span.Context(),
+		opentracing.HTTPHeaders,
+		opentracing.HTTPHeadersCarrier(req.Header),
+	)
+}
+
+// finish records the outcome of one node's request onto its span: HTTP
+// status, response size in bytes, and how many retries were needed. It also
+// appends a row to information_schema.cluster_request_trace so the request
+// is visible without a Jaeger collector.
+func (s *clusterRequestSpan) finish(status int, responseSize int, err error) {
+	s.span.SetTag("http.status_code", status)
+	s.span.SetTag("response.size_bytes", responseSize)
+	s.span.SetTag("retry.count", s.retryCount)
+	if err != nil {
+		s.span.SetTag("error", true)
+		s.span.LogKV("event", "error", "message", err.Error())
+	}
+	s.span.Finish()
+	s.recordRequestTrace(status, err)
+}
+
+// recordRequestTrace appends one row to cluster_request_trace for this
+// node's request. It is a best-effort audit trail, not the trace itself: a
+// write failure is surfaced as a warning rather than failing the statement
+// that is already done executing.
+func (s *clusterRequestSpan) recordRequestTrace(status int, err error) {
+	if s.ctx == nil {
+		return
+	}
+	exec, ok := s.ctx.(sqlexec.RestrictedSQLExecutor)
+	if !ok {
+		return
+	}
+	statusText := "ok"
+	if err != nil {
+		statusText = err.Error()
+	}
+	durationMs := time.Since(s.start).Milliseconds()
+	sql := fmt.Sprintf(
+		"INSERT INTO information_schema.cluster_request_trace "+
+			"(trace_id, statement, start_time, duration_ms, type, address, node_duration_ms, status, retry_count) VALUES "+
+			"('%s', '%s', '%s', %d, '%s', '%s', %d, '%s', %d)",
+		uuid.New().String(), escapeSQLString(s.statement), s.start.Format("2006-01-02 15:04:05.000"), durationMs,
+		escapeSQLString(s.typ), escapeSQLString(s.address), durationMs, escapeSQLString(statusText), s.retryCount)
+	if _, _, err := exec.ExecRestrictedSQL(sql); err != nil {
+		s.ctx.GetSessionVars().StmtCtx.AppendWarning(err)
+	}
+}
+
+// recordingTracer is a minimal in-memory opentracing.Tracer used by tests to
+// assert the shape of the span tree produced by a cluster reader fan-out,
+// without standing up a real Jaeger collector.
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+type recordingSpan struct {
+	opentracing.Span
+	operationName string
+	parent        opentracing.SpanContext
+	tags          map[string]interface{}
+	finished      time.Time
+}
+
+func newRecordingTracer() *recordingTracer {
+	return &recordingTracer{}
+}
+
+func (t *recordingTracer) StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	sso := opentracing.StartSpanOptions{}
+	for _, o := range opts {
+		o.Apply(&sso)
+	}
+	var parent opentracing.SpanContext
+	for _, ref := range sso.References {
+		if ref.Type == opentracing.ChildOfRef {
+			parent = ref.ReferencedContext
+		}
+	}
+	s := &recordingSpan{operationName: operationName, parent: parent, tags: map[string]interface{}{}}
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	t.mu.Unlock()
+	return s
+}
+
+func (t *recordingTracer) Inject(sm opentracing.SpanContext, format interface{}, carrier interface{}) error {
+	return nil
+}
+
+func (t *recordingTracer) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
+	return nil, opentracing.ErrSpanContextNotFound
+}
+
+func (s *recordingSpan) SetTag(key string, value interface{}) opentracing.Span {
+	s.tags[key] = value
+	return s
+}
+func (s *recordingSpan) Finish()                                   { s.finished = time.Now() }
+func (s *recordingSpan) Context() opentracing.SpanContext          { return nil }
+func (s *recordingSpan) LogKV(alternatingKeyValues ...interface{}) {}
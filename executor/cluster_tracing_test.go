@@ -0,0 +1,40 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	opentracing "github.com/opentracing/opentracing-go"
+	. "github.com/pingcap/check"
+)
+
+type testClusterTracingSuite struct{}
+
+var _ = Suite(&testClusterTracingSuite{})
+
+func (s *testClusterTracingSuite) TestSpanTree(c *C) {
+	tracer := newRecordingTracer()
+	initClusterRequestTracer(tracer)
+	defer initClusterRequestTracer(opentracing.NoopTracer{})
+
+	root := startClusterRequestRootSpan("select * from information_schema.cluster_config")
+	child := startClusterRequestChildSpan(nil, root, "select * from information_schema.cluster_config", "tikv", "127.0.0.1:20160")
+	child.finish(200, 128, nil)
+	root.Finish()
+
+	c.Assert(tracer.spans, HasLen, 2)
+	c.Assert(tracer.spans[0].operationName, Equals, "cluster_reader")
+	c.Assert(tracer.spans[1].operationName, Equals, "cluster_reader.fetch")
+	c.Assert(tracer.spans[1].tags["node.type"], Equals, "tikv")
+	c.Assert(tracer.spans[1].tags["http.status_code"], Equals, 200)
+}
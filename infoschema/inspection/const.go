@@ -21,6 +21,42 @@ const tableClusterLog = "CREATE TABLE %s.CLUSTER_LOG(" +
 	"LEVEL varchar(10)," +
 	"CONTENT text);"
 
+// tableClusterConfigHistory records every configuration item that has been
+// changed through `UPDATE information_schema.cluster_config`, regardless of
+// whether the change ultimately succeeded on the target node.
+const tableClusterConfigHistory = "CREATE TABLE IF NOT EXISTS %s.CLUSTER_CONFIG_HISTORY (" +
+	"CHANGE_TIME timestamp NOT NULL," +
+	"TYPE varchar(64) NOT NULL," +
+	"ADDRESS varchar(64) NOT NULL," +
+	"`KEY` varchar(256) NOT NULL," +
+	"OLD_VALUE varchar(1024) DEFAULT NULL," +
+	"NEW_VALUE varchar(1024) NOT NULL," +
+	"SUCCESS tinyint(1) NOT NULL," +
+	"MESSAGE varchar(512) DEFAULT NULL);"
+
+// tableClusterRequestTrace surfaces the most recent root traces produced by
+// the cluster reader's fan-out (cluster_config, cluster_log, ...) so an
+// operator can see per-node latencies without running a Jaeger deployment.
+const tableClusterRequestTrace = "CREATE TABLE IF NOT EXISTS %s.CLUSTER_REQUEST_TRACE (" +
+	"TRACE_ID varchar(36) NOT NULL," +
+	"STATEMENT text NOT NULL," +
+	"START_TIME timestamp NOT NULL," +
+	"DURATION_MS bigint(20) NOT NULL," +
+	"TYPE varchar(64) DEFAULT NULL," +
+	"ADDRESS varchar(64) DEFAULT NULL," +
+	"NODE_DURATION_MS bigint(20) DEFAULT NULL," +
+	"STATUS varchar(512) NOT NULL," +
+	"RETRY_COUNT int(8) NOT NULL);"
+
+// tableClusterLogExtractors lets a user register a named regexp with capture
+// groups against cluster_log.message; the first capture group then shows up
+// as the queryable virtual column COLUMN_NAME (see
+// executor.ClusterLogExtractorRegistry).
+const tableClusterLogExtractors = "CREATE TABLE IF NOT EXISTS %s.CLUSTER_LOG_EXTRACTORS (" +
+	"NAME varchar(64) NOT NULL," +
+	"COLUMN_NAME varchar(64) NOT NULL," +
+	"PATTERN varchar(512) NOT NULL);"
+
 var inspectionPersistTables = []string{
 	tableTiDBClusterInfo,
 	tableSystemInfo,
@@ -32,6 +68,11 @@ var inspectionPersistTables = []string{
 	tableTiDBCpuProfile,
 	tableTiKVCpuProfile,
 	tableSlowQueryDetail,
+	tableClusterConfigHistory,
+	tableClusterRequestTrace,
+	tableClusterLogExtractors,
+	tableTiDBHeapProfile,
+	tableClusterProfile,
 }
 
 const tableTiDBClusterInfo = `CREATE TABLE %s.TIDB_CLUSTER_INFO (
@@ -161,6 +202,35 @@ const tableTiKVCpuProfile = "CREATE TABLE IF NOT EXISTS %s.TIKV_CPU_PROFILE (" +
 	"DEPTH INT(8) NOT NULL," +
 	"FILE VARCHAR(512) NOT NULL);"
 
+// tableTiDBHeapProfile contains the columns name definitions for table
+// tidb_heap_profile. It shares its row shape with tidb_cpu_profile; only the
+// KIND column (heap/mutex/block/allocs/goroutine) and DIFF flag are new,
+// since both tables are produced by the same profileToDatums tree renderer.
+const tableTiDBHeapProfile = "CREATE TABLE IF NOT EXISTS %s.TIDB_HEAP_PROFILE (" +
+	"KIND VARCHAR(16) NOT NULL," +
+	"DIFF TINYINT(1) NOT NULL," +
+	"FUNCTION VARCHAR(512) NOT NULL," +
+	"PERCENT_ABS VARCHAR(8) NOT NULL," +
+	"PERCENT_REL VARCHAR(8) NOT NULL," +
+	"ROOT_CHILD INT(8) NOT NULL," +
+	"DEPTH INT(8) NOT NULL," +
+	"FILE VARCHAR(512) NOT NULL);"
+
+// tableClusterProfile is the cluster-wide counterpart of tidb_heap_profile:
+// one row set per (type, address) target, for any profileKind, optionally
+// diffed between two snapshots.
+const tableClusterProfile = "CREATE TABLE IF NOT EXISTS %s.CLUSTER_PROFILE (" +
+	"TYPE VARCHAR(16) NOT NULL," +
+	"ADDRESS VARCHAR(64) NOT NULL," +
+	"KIND VARCHAR(16) NOT NULL," +
+	"DIFF TINYINT(1) NOT NULL," +
+	"FUNCTION VARCHAR(512) NOT NULL," +
+	"PERCENT_ABS VARCHAR(8) NOT NULL," +
+	"PERCENT_REL VARCHAR(8) NOT NULL," +
+	"ROOT_CHILD INT(8) NOT NULL," +
+	"DEPTH INT(8) NOT NULL," +
+	"FILE VARCHAR(512) NOT NULL);"
+
 const tableSlowQueryDetail = "CREATE TABLE IF NOT EXISTS %s.SLOW_QUERY_DETAIL (" +
 	"ID BIGINT(20) NOT NULL," +
 	"TYPE VARCHAR(8) NOT NULL," +
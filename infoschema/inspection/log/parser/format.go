@@ -0,0 +1,240 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+)
+
+// Format identifies how a component chose to emit its log lines.
+type Format string
+
+// The set of formats a component can declare for its log file.
+const (
+	// FormatText is TiDB's historical plaintext format:
+	// `[2019/08/26 06:19:13.011 +00:00] [INFO] [message]`.
+	FormatText Format = "text"
+	// FormatJSON is one JSON object per line, e.g. zap's JSON encoder.
+	FormatJSON Format = "json"
+	// FormatLogfmt is `key=value key2="value 2"` per line.
+	FormatLogfmt Format = "logfmt"
+	// FormatZapDev is zap's development encoder: a tab-separated prefix
+	// followed by a trailing JSON object of extra fields.
+	FormatZapDev Format = "zap-dev"
+)
+
+// Record is a parsed log line: the well-known columns plus, in Fields,
+// whatever else the component attached. No cluster_log column exposes Fields.
+type Record struct {
+	Time    time.Time
+	Level   Level
+	Caller  string
+	Message string
+	Fields  map[string]string
+}
+
+// DetectFormat sniffs a single log line to guess which Format produced it.
+// Callers that know the format ahead of time (e.g. from a per-server config
+// override) should skip this and call ParseLine directly.
+func DetectFormat(line []byte) Format {
+	trimmed := bytes.TrimSpace(line)
+	switch {
+	case len(trimmed) == 0:
+		return FormatText
+	case trimmed[0] == '{':
+		return FormatJSON
+	case bytes.HasPrefix(trimmed, []byte("20")) && bytes.Contains(trimmed, []byte("\t")):
+		// zap's development encoder starts with an ISO-8601 timestamp and
+		// separates fields with tabs.
+		return FormatZapDev
+	case trimmed[0] == '[':
+		return FormatText
+	default:
+		return FormatLogfmt
+	}
+}
+
+// ParseLine parses a single log line according to format, extracting the
+// standard ts/level/msg/caller fields into Record and leaving everything
+// else (JSON, logfmt, and zap-dev's trailing JSON object; not text) in
+// Record.Fields.
+func ParseLine(format Format, line []byte) (*Record, error) {
+	switch format {
+	case FormatJSON:
+		return parseJSON(line)
+	case FormatLogfmt:
+		return parseLogfmt(line)
+	case FormatZapDev:
+		return parseZapDev(line)
+	default:
+		return parseText(line)
+	}
+}
+
+func parseJSON(line []byte) (*Record, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return nil, errors.Trace(err)
+	}
+	rec := &Record{Fields: map[string]string{}}
+	for _, key := range []string{"ts", "time", "@timestamp"} {
+		if v, ok := raw[key]; ok {
+			rec.Time = parseAnyTime(toString(v))
+			delete(raw, key)
+			break
+		}
+	}
+	if v, ok := raw["level"]; ok {
+		rec.Level = ParseLogLevel([]byte(toString(v)))
+		delete(raw, "level")
+	}
+	if v, ok := raw["msg"]; ok {
+		rec.Message = toString(v)
+		delete(raw, "msg")
+	}
+	if v, ok := raw["caller"]; ok {
+		rec.Caller = toString(v)
+		delete(raw, "caller")
+	}
+	for k, v := range raw {
+		rec.Fields[k] = toString(v)
+	}
+	return rec, nil
+}
+
+func parseLogfmt(line []byte) (*Record, error) {
+	rec := &Record{Fields: map[string]string{}}
+	for _, pair := range splitLogfmt(string(line)) {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := kv[0], strings.Trim(kv[1], `"`)
+		switch key {
+		case "ts", "time":
+			rec.Time = parseAnyTime(value)
+		case "level":
+			rec.Level = ParseLogLevel([]byte(value))
+		case "msg":
+			rec.Message = value
+		case "caller":
+			rec.Caller = value
+		default:
+			rec.Fields[key] = value
+		}
+	}
+	return rec, nil
+}
+
+// parseZapDev parses zap's development encoder: a tab-separated
+// `ts\tlevel\tcaller\tmessage` prefix, optionally followed by a tab and a
+// trailing JSON object of context fields.
+func parseZapDev(line []byte) (*Record, error) {
+	parts := bytes.SplitN(line, []byte("\t"), 5)
+	if len(parts) < 4 {
+		return nil, errors.Errorf("malformed zap development log line: %q", line)
+	}
+	rec := &Record{
+		Time:    parseAnyTime(string(parts[0])),
+		Level:   ParseLogLevel(parts[1]),
+		Caller:  string(parts[2]),
+		Message: string(parts[3]),
+		Fields:  map[string]string{},
+	}
+	if len(parts) == 5 {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(bytes.TrimSpace(parts[4]), &fields); err == nil {
+			for k, v := range fields {
+				rec.Fields[k] = toString(v)
+			}
+		}
+	}
+	return rec, nil
+}
+
+// parseText parses TiDB's default plaintext format, e.g.
+// `[2019/08/26 06:19:13.011 +00:00] [INFO] [message]`. The timestamp itself
+// contains two spaces (date, time, UTC offset), so it takes the first three
+// tokens; the fourth is the level, and everything after that is the message.
+func parseText(line []byte) (*Record, error) {
+	fields := bytes.SplitN(bytes.TrimSpace(line), []byte(" "), 5)
+	if len(fields) < 4 {
+		return nil, errors.Errorf("malformed plaintext log line: %q", line)
+	}
+	ts := bytes.Join(fields[0:3], []byte(" "))
+	rec := &Record{
+		Time:  parseAnyTime(string(bytes.Trim(ts, "[]"))),
+		Level: ParseLogLevel(bytes.Trim(fields[3], "[]")),
+	}
+	if len(fields) == 5 {
+		rec.Message = string(bytes.Trim(fields[4], "[]"))
+	}
+	return rec, nil
+}
+
+func splitLogfmt(s string) []string {
+	var fields []string
+	var buf strings.Builder
+	inQuote := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			buf.WriteRune(r)
+		case r == ' ' && !inQuote:
+			if buf.Len() > 0 {
+				fields = append(fields, buf.String())
+				buf.Reset()
+			}
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	if buf.Len() > 0 {
+		fields = append(fields, buf.String())
+	}
+	return fields
+}
+
+func toString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	default:
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+}
+
+// the timestamp layouts accepted across text/json/logfmt/zap-dev sources.
+var timeLayouts = []string{
+	"2006/01/02 15:04:05.000 -07:00",
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05.000Z0700",
+}
+
+func parseAnyTime(s string) time.Time {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"testing"
+
+	. "github.com/pingcap/check"
+)
+
+func TestT(t *testing.T) {
+	TestingT(t)
+}
+
+type testFormatSuite struct{}
+
+var _ = Suite(&testFormatSuite{})
+
+func (s *testFormatSuite) TestDetectFormat(c *C) {
+	c.Assert(DetectFormat([]byte(`{"level":"info","ts":"2019-08-26T06:19:13.011Z","msg":"hello"}`)), Equals, FormatJSON)
+	c.Assert(DetectFormat([]byte(`[2019/08/26 06:19:13.011 +00:00] [INFO] [hello]`)), Equals, FormatText)
+	c.Assert(DetectFormat([]byte(`level=info ts=2019-08-26T06:19:13.011Z msg=hello`)), Equals, FormatLogfmt)
+	c.Assert(DetectFormat([]byte("2019-08-26T06:19:13.011Z\tINFO\tmain.go:1\thello\t{\"region_id\":42}")), Equals, FormatZapDev)
+}
+
+func (s *testFormatSuite) TestParseJSON(c *C) {
+	rec, err := ParseLine(FormatJSON, []byte(`{"level":"info","ts":"2019-08-26T06:19:13.011Z","msg":"hello","region_id":42}`))
+	c.Assert(err, IsNil)
+	c.Assert(rec.Level, Equals, LevelInfo)
+	c.Assert(rec.Message, Equals, "hello")
+	c.Assert(rec.Fields["region_id"], Equals, "42")
+}
+
+func (s *testFormatSuite) TestParseLogfmt(c *C) {
+	rec, err := ParseLine(FormatLogfmt, []byte(`level=info ts=2019-08-26T06:19:13.011Z msg=hello region_id=42`))
+	c.Assert(err, IsNil)
+	c.Assert(rec.Level, Equals, LevelInfo)
+	c.Assert(rec.Message, Equals, "hello")
+	c.Assert(rec.Fields["region_id"], Equals, "42")
+}
+
+func (s *testFormatSuite) TestParseZapDev(c *C) {
+	rec, err := ParseLine(FormatZapDev, []byte("2019-08-26T06:19:13.011Z\tINFO\tmain.go:1\thello\t{\"region_id\":42}"))
+	c.Assert(err, IsNil)
+	c.Assert(rec.Level, Equals, LevelInfo)
+	c.Assert(rec.Caller, Equals, "main.go:1")
+	c.Assert(rec.Message, Equals, "hello")
+	c.Assert(rec.Fields["region_id"], Equals, "42")
+}
+
+func (s *testFormatSuite) TestParseText(c *C) {
+	rec, err := ParseLine(FormatText, []byte(`[2019/08/26 06:19:13.011 +00:00] [INFO] [hello]`))
+	c.Assert(err, IsNil)
+	c.Assert(rec.Level, Equals, LevelInfo)
+	c.Assert(rec.Message, Equals, "hello")
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package parser turns a raw log line into the fields the log-search
+// subsystem needs (timestamp, level, message), and recognises the level
+// regardless of case or brackets (`[INFO]`, `info`, ...).
+package parser
+
+import "bytes"
+
+// Level is a normalized log level, independent of how the source component
+// chose to spell it.
+type Level int
+
+// The set of levels every component log line can carry.
+const (
+	LevelUnknown Level = iota
+	LevelTrace
+	LevelDebug
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelCritical
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelTrace:
+		return "TRACE"
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	case LevelCritical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ParseLogLevel normalizes a level spelled in any case, with or without
+// surrounding brackets, into a Level.
+func ParseLogLevel(level []byte) Level {
+	level = bytes.Trim(bytes.ToUpper(level), "[]")
+	switch string(level) {
+	case "TRACE":
+		return LevelTrace
+	case "DEBUG":
+		return LevelDebug
+	case "INFO":
+		return LevelInfo
+	case "WARN", "WARNING":
+		return LevelWarn
+	case "ERROR":
+		return LevelError
+	case "CRITICAL", "FATAL":
+		return LevelCritical
+	default:
+		return LevelUnknown
+	}
+}
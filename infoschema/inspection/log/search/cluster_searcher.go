@@ -0,0 +1,403 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/infoschema/inspection/log/item"
+	"github.com/pingcap/tidb/infoschema/inspection/log/iterator"
+	"github.com/pingcap/tidb/infoschema/inspection/log/parser"
+	"github.com/pingcap/tidb/sessionctx"
+	"github.com/pingcap/tidb/util/sqlexec"
+)
+
+// clusterIterWithAccessTime is the cluster-wide counterpart of
+// IterWithAccessTime: it tracks the merged multi-node iterator under a
+// token, using the exact same 60s idle Gc policy as the local searcher, so a
+// SQL client pages a cluster-wide cursor the same way it pages a local one.
+// dir/level/text/end are the parameters the merged iterator was built from,
+// kept so Resume can re-run the fan-out from a later begin without the
+// caller having to repeat them.
+type clusterIterWithAccessTime struct {
+	iter   iterator.Iterator
+	access time.Time
+	l      sync.Mutex
+
+	dir, level, text string
+	end              time.Time
+}
+
+func (i *clusterIterWithAccessTime) Next() (item.Item, error) {
+	i.l.Lock()
+	defer i.l.Unlock()
+	i.access = time.Now()
+	return i.iter.Next()
+}
+
+func (i *clusterIterWithAccessTime) Close() error {
+	i.l.Lock()
+	defer i.l.Unlock()
+	return i.iter.Close()
+}
+
+func (i *clusterIterWithAccessTime) GetAccessTime() time.Time {
+	i.l.Lock()
+	defer i.l.Unlock()
+	return i.access
+}
+
+// clusterSession is one live cluster-wide cursor: the merged iterator plus
+// the cancel func that stops its gc goroutine via ctx, the same model
+// (*searcher) in search.go uses, instead of racing time.Sleep against
+// access time.
+type clusterSession struct {
+	iter   *clusterIterWithAccessTime
+	cancel context.CancelFunc
+}
+
+// ClusterSearcher is a Searcher whose Search fans a single log query out to
+// every TiDB/TiKV/PD node in the cluster, following the same
+// lookup-peers-then-concurrent-HTTP-GET pattern as tikvCpuProfileGraph, and
+// merges the per-node results into one timestamp-ordered iterator. It shares
+// the token/session model and idle Gc of the local searcher so callers can
+// page a cluster-wide cursor from SQL exactly as they page a local one.
+type ClusterSearcher struct {
+	mu       sync.Mutex
+	sessions map[string]*clusterSession
+	ctx      sessionctx.Context
+	client   *http.Client
+}
+
+// NewClusterSearcher builds a ClusterSearcher that resolves cluster members
+// through ctx's restricted SQL executor (the same mechanism perfschema uses
+// for INFORMATION_SCHEMA.TIDB_CLUSTER_INFO lookups).
+func NewClusterSearcher(ctx sessionctx.Context) *ClusterSearcher {
+	return &ClusterSearcher{
+		sessions: make(map[string]*clusterSession),
+		ctx:      ctx,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *ClusterSearcher) setSession(token string, sess *clusterSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[token] = sess
+}
+
+func (s *ClusterSearcher) getSession(token string) *clusterSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[token]
+}
+
+func (s *ClusterSearcher) delSession(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, token)
+}
+
+// gc expires a cluster cursor 60s after its last access, unless ctx is
+// cancelled first by CloseSession or Resume ending the session early.
+func (s *ClusterSearcher) gc(ctx context.Context, token string, iter *clusterIterWithAccessTime) {
+	const idle = 60 * time.Second
+	ticker := time.NewTicker(idle)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if iter.GetAccessTime().Add(idle).Before(time.Now()) {
+				s.delSession(token)
+				iter.Close()
+				return
+			}
+		}
+	}
+}
+
+type clusterNode struct {
+	name          string
+	address       string
+	statusAddress string
+}
+
+func (s *ClusterSearcher) listNodes() ([]clusterNode, error) {
+	exec, ok := s.ctx.(sqlexec.RestrictedSQLExecutor)
+	if !ok {
+		return nil, errors.New("session does not support restricted SQL execution")
+	}
+	sql := "SELECT name, address, status_address FROM INFORMATION_SCHEMA.TIDB_CLUSTER_INFO"
+	rows, _, err := exec.ExecRestrictedSQL(sql)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	nodes := make([]clusterNode, 0, len(rows))
+	for _, row := range rows {
+		nodes = append(nodes, clusterNode{
+			name:          row.GetString(0),
+			address:       row.GetString(1),
+			statusAddress: row.GetString(2),
+		})
+	}
+	return nodes, nil
+}
+
+// Search implements Searcher. When token is empty, it resolves every
+// cluster member, opens one scan per node for [begin, end], and returns a
+// single iterator that merges them in timestamp order. A node-level failure
+// is appended as a warning on StmtCtx rather than aborting the whole query,
+// matching how tikv profile errors are surfaced today.
+func (s *ClusterSearcher) Search(dir string, begin, end time.Time, level, text, token string) (iterator.Iterator, string, error) {
+	if token != "" {
+		if sess := s.getSession(token); sess != nil {
+			return sess.iter, token, nil
+		}
+		return nil, token, errors.New("target not found")
+	}
+
+	merged, err := s.fanOut(dir, begin, end, level, text)
+	if err != nil {
+		return nil, "", errors.Trace(err)
+	}
+
+	token = uuid.New().String()
+	tracked := &clusterIterWithAccessTime{iter: merged, access: time.Now(), dir: dir, level: level, text: text, end: end}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.setSession(token, &clusterSession{iter: tracked, cancel: cancel})
+	go s.gc(ctx, token, tracked)
+	return tracked, token, nil
+}
+
+// fanOut resolves every cluster member and opens one scan per node for
+// [begin, end], returning a single iterator that merges them in timestamp
+// order. A node-level failure is appended as a warning on StmtCtx rather
+// than aborting the whole query.
+func (s *ClusterSearcher) fanOut(dir string, begin, end time.Time, level, text string) (iterator.Iterator, error) {
+	nodes, err := s.listNodes()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		iters []iterator.Iterator
+	)
+	for _, n := range nodes {
+		if n.statusAddress == "" {
+			s.ctx.GetSessionVars().StmtCtx.AppendWarning(
+				errors.Errorf("node %s(%s) does not have a status address, skipped", n.name, n.address))
+			continue
+		}
+		n := n
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			iter, err := s.searchOne(n, dir, begin, end, level, text)
+			if err != nil {
+				s.ctx.GetSessionVars().StmtCtx.AppendWarning(
+					errors.Errorf("search logs on %s(%s) failed: %s", n.name, n.statusAddress, err))
+				return
+			}
+			mu.Lock()
+			iters = append(iters, iter)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return newMergeIterator(iters), nil
+}
+
+// CloseSession implements Searcher, tearing down the merged cursor
+// identified by token immediately: it cancels the session's gc goroutine
+// instead of waiting for it to idle out, mirroring (*searcher).CloseSession.
+func (s *ClusterSearcher) CloseSession(token string) error {
+	sess := s.getSession(token)
+	if sess == nil {
+		return errors.New("target not found")
+	}
+	sess.cancel()
+	s.delSession(token)
+	return sess.iter.Close()
+}
+
+// Resume implements Searcher. The merged cluster iterator has no seekable
+// Sequence per node the way the local searcher does, so instead of seeking
+// in place, Resume closes every node's current scan and re-runs the fan-out
+// from afterTimestamp to the original end, keeping the original dir/level/
+// text and token; afterOffset is unused since per-node file offsets are not
+// comparable across nodes once merged.
+func (s *ClusterSearcher) Resume(token string, afterTimestamp time.Time, afterOffset int64) (iterator.Iterator, error) {
+	old := s.getSession(token)
+	if old == nil {
+		return nil, errors.New("target not found")
+	}
+	old.cancel()
+	old.iter.Close()
+
+	merged, err := s.fanOut(old.iter.dir, afterTimestamp, old.iter.end, old.iter.level, old.iter.text)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	tracked := &clusterIterWithAccessTime{
+		iter: merged, access: time.Now(),
+		dir: old.iter.dir, level: old.iter.level, text: old.iter.text, end: old.iter.end,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.setSession(token, &clusterSession{iter: tracked, cancel: cancel})
+	go s.gc(ctx, token, tracked)
+	return tracked, nil
+}
+
+func (s *ClusterSearcher) searchOne(n clusterNode, dir string, begin, end time.Time, level, text string) (iterator.Iterator, error) {
+	url := fmt.Sprintf("http://%s/log?dir=%s&begin=%d&end=%d&level=%s&text=%s",
+		n.statusAddress, neturl.QueryEscape(dir), begin.UnixNano()/int64(time.Millisecond), end.UnixNano()/int64(time.Millisecond),
+		neturl.QueryEscape(level), neturl.QueryEscape(text))
+	resp, err := s.client.Get(url)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return newRemoteIterator(n.name, n.address, resp), nil
+}
+
+// mergeHeap k-way merges the per-node iterators by timestamp.
+type mergeHeapEntry struct {
+	it  iterator.Iterator
+	cur item.Item
+}
+
+type mergeHeap []*mergeHeapEntry
+
+func (h mergeHeap) Len() int            { return len(h) }
+func (h mergeHeap) Less(i, j int) bool  { return h[i].cur.GetTime().Before(h[j].cur.GetTime()) }
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(*mergeHeapEntry)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	*h = old[:n-1]
+	return e
+}
+
+// mergeIterator merges several per-node iterators into one, sorted by
+// timestamp.
+type mergeIterator struct {
+	h *mergeHeap
+}
+
+func newMergeIterator(iters []iterator.Iterator) iterator.Iterator {
+	h := &mergeHeap{}
+	heap.Init(h)
+	for _, it := range iters {
+		if cur, err := it.Next(); err == nil {
+			heap.Push(h, &mergeHeapEntry{it: it, cur: cur})
+		} else {
+			// A node with zero matching lines (including a clean io.EOF on an
+			// empty range) never gets pushed onto the heap, so it must be
+			// closed here or its HTTP response body leaks for the lifetime
+			// of the merged cursor.
+			it.Close()
+		}
+	}
+	return &mergeIterator{h: h}
+}
+
+func (m *mergeIterator) Next() (item.Item, error) {
+	if m.h.Len() == 0 {
+		return nil, io.EOF
+	}
+	top := heap.Pop(m.h).(*mergeHeapEntry)
+	result := top.cur
+	if next, err := top.it.Next(); err == nil {
+		top.cur = next
+		heap.Push(m.h, top)
+	} else {
+		top.it.Close()
+	}
+	return result, nil
+}
+
+func (m *mergeIterator) Close() error {
+	for _, e := range *m.h {
+		e.it.Close()
+	}
+	return nil
+}
+
+// remoteLogItem is one line received from a peer's `/log` endpoint.
+type remoteLogItem struct {
+	t       time.Time
+	level   parser.Level
+	content []byte
+}
+
+func (r *remoteLogItem) GetTime() time.Time     { return r.t }
+func (r *remoteLogItem) GetLevel() parser.Level { return r.level }
+func (r *remoteLogItem) GetContent() []byte     { return r.content }
+
+// remoteIterator turns one peer's `/log` response body, one line per log
+// entry formatted as `<unix-nano>\t<level>\t<content>`, into an
+// iterator.Iterator so it can be merged with every other node's results.
+type remoteIterator struct {
+	name, address string
+	resp          *http.Response
+	scanner       *bufio.Scanner
+}
+
+func newRemoteIterator(name, address string, resp *http.Response) iterator.Iterator {
+	return &remoteIterator{name: name, address: address, resp: resp, scanner: bufio.NewScanner(resp.Body)}
+}
+
+func (r *remoteIterator) Next() (item.Item, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return nil, errors.Trace(err)
+		}
+		return nil, io.EOF
+	}
+	parts := strings.SplitN(r.scanner.Text(), "\t", 3)
+	if len(parts) != 3 {
+		return nil, errors.Errorf("malformed remote log line from %s(%s): %q", r.name, r.address, r.scanner.Text())
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &remoteLogItem{
+		t:       time.Unix(0, nanos),
+		level:   parser.ParseLogLevel([]byte(parts[1])),
+		content: []byte(parts[2]),
+	}, nil
+}
+
+func (r *remoteIterator) Close() error {
+	return r.resp.Body.Close()
+}
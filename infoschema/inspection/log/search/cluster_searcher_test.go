@@ -0,0 +1,100 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"context"
+	"io"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb/infoschema/inspection/log/item"
+	"github.com/pingcap/tidb/infoschema/inspection/log/iterator"
+	"github.com/pingcap/tidb/infoschema/inspection/log/parser"
+)
+
+type fakeLogItem struct {
+	t time.Time
+}
+
+func (f fakeLogItem) GetTime() time.Time     { return f.t }
+func (f fakeLogItem) GetLevel() parser.Level { return parser.LevelInfo }
+func (f fakeLogItem) GetContent() []byte     { return nil }
+
+type fakeMergeSource struct {
+	values []item.Item
+	closed bool
+}
+
+func (f *fakeMergeSource) Next() (item.Item, error) {
+	if len(f.values) == 0 {
+		return nil, io.EOF
+	}
+	v := f.values[0]
+	f.values = f.values[1:]
+	return v, nil
+}
+
+func (f *fakeMergeSource) Close() error {
+	f.closed = true
+	return nil
+}
+
+type testClusterSearcherSuite struct{}
+
+var _ = Suite(&testClusterSearcherSuite{})
+
+func (s *testClusterSearcherSuite) TestNewMergeIteratorClosesEmptySources(c *C) {
+	empty := &fakeMergeSource{}
+	withData := &fakeMergeSource{values: []item.Item{fakeLogItem{t: time.Now()}}}
+
+	merged := newMergeIterator([]iterator.Iterator{empty, withData})
+	defer merged.Close()
+
+	c.Assert(empty.closed, IsTrue)
+	c.Assert(withData.closed, IsFalse)
+}
+
+func (s *testClusterSearcherSuite) TestCloseSessionCancelsGcAndRemovesToken(c *C) {
+	cs := &ClusterSearcher{sessions: make(map[string]*clusterSession)}
+	src := &fakeMergeSource{values: []item.Item{fakeLogItem{t: time.Now()}}}
+	tracked := &clusterIterWithAccessTime{iter: newMergeIterator([]iterator.Iterator{src}), access: time.Now()}
+	ctx, cancel := context.WithCancel(context.Background())
+	cs.setSession("tok", &clusterSession{iter: tracked, cancel: cancel})
+	done := make(chan struct{})
+	go func() {
+		cs.gc(ctx, "tok", tracked)
+		close(done)
+	}()
+
+	c.Assert(cs.CloseSession("tok"), IsNil)
+	c.Assert(cs.getSession("tok"), IsNil)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		c.Fatal("gc did not stop after CloseSession cancelled its context")
+	}
+}
+
+func (s *testClusterSearcherSuite) TestCloseSessionUnknownToken(c *C) {
+	cs := &ClusterSearcher{sessions: make(map[string]*clusterSession)}
+	c.Assert(cs.CloseSession("no-such-token"), ErrorMatches, "target not found")
+}
+
+func (s *testClusterSearcherSuite) TestResumeUnknownToken(c *C) {
+	cs := &ClusterSearcher{sessions: make(map[string]*clusterSession)}
+	_, err := cs.Resume("no-such-token", time.Now(), 0)
+	c.Assert(err, ErrorMatches, "target not found")
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"testing"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb/infoschema/inspection/log/parser"
+)
+
+func TestT(t *testing.T) {
+	TestingT(t)
+}
+
+type testSearchOptionsSuite struct{}
+
+var _ = Suite(&testSearchOptionsSuite{})
+
+func (s *testSearchOptionsSuite) TestPlainTextMatch(c *C) {
+	opts, err := SearchOptions{Text: "foo"}.compile()
+	c.Assert(err, IsNil)
+	c.Assert(opts.matchesContent([]byte("contains foo bar")), IsTrue)
+	c.Assert(opts.matchesContent([]byte("no match")), IsFalse)
+}
+
+func (s *testSearchOptionsSuite) TestRegexTextMatch(c *C) {
+	opts, err := SearchOptions{Text: `re:region_id=\d+`}.compile()
+	c.Assert(err, IsNil)
+	c.Assert(opts.matchesContent([]byte("region_id=42")), IsTrue)
+	c.Assert(opts.matchesContent([]byte("region_id=abc")), IsFalse)
+}
+
+func (s *testSearchOptionsSuite) TestMultiLevelMatch(c *C) {
+	opts, err := SearchOptions{Levels: []string{"WARN", "ERROR"}}.compile()
+	c.Assert(err, IsNil)
+	c.Assert(opts.matchesLevel(parser.LevelWarn), IsTrue)
+	c.Assert(opts.matchesLevel(parser.LevelError), IsTrue)
+	c.Assert(opts.matchesLevel(parser.LevelInfo), IsFalse)
+}
+
+func (s *testSearchOptionsSuite) TestContentSizeBound(c *C) {
+	opts, err := SearchOptions{MaxContentSize: 4}.compile()
+	c.Assert(err, IsNil)
+	c.Assert(opts.matchesContent([]byte("ab")), IsTrue)
+	c.Assert(opts.matchesContent([]byte("abcdef")), IsFalse)
+}
+
+func (s *testSearchOptionsSuite) TestComponentGlob(c *C) {
+	opts, err := SearchOptions{Component: "tikv*.log"}.compile()
+	c.Assert(err, IsNil)
+	c.Assert(opts.matchesComponent("tikv-1.log"), IsTrue)
+	c.Assert(opts.matchesComponent("pd-1.log"), IsFalse)
+}
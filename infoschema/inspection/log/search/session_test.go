@@ -0,0 +1,89 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"io"
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+type testSessionSuite struct{}
+
+var _ = Suite(&testSessionSuite{})
+
+func (s *testSessionSuite) TestTooManySessionsRejected(c *C) {
+	sr := NewSearcherWithMaxSessions(1).(*searcher)
+	dir := c.MkDir()
+
+	_, token1, err := sr.SearchWithOptions(SearchOptions{Dir: dir})
+	c.Assert(err, IsNil)
+	c.Assert(token1, Not(Equals), "")
+
+	_, _, err = sr.SearchWithOptions(SearchOptions{Dir: dir})
+	c.Assert(err, NotNil)
+	_, ok := err.(*ErrTooManySessions)
+	c.Assert(ok, IsTrue)
+
+	c.Assert(sr.CloseSession(token1), IsNil)
+
+	_, token2, err := sr.SearchWithOptions(SearchOptions{Dir: dir})
+	c.Assert(err, IsNil)
+	c.Assert(token2, Not(Equals), "")
+	c.Assert(sr.CloseSession(token2), IsNil)
+}
+
+func (s *testSessionSuite) TestCloseSessionRemovesToken(c *C) {
+	sr := NewSearcher().(*searcher)
+	dir := c.MkDir()
+
+	_, token, err := sr.SearchWithOptions(SearchOptions{Dir: dir})
+	c.Assert(err, IsNil)
+
+	c.Assert(sr.CloseSession(token), IsNil)
+	c.Assert(sr.getSession(token), IsNil)
+
+	_, _, err = sr.SearchWithOptions(SearchOptions{Token: token})
+	c.Assert(err, ErrorMatches, "target not found")
+}
+
+func (s *testSessionSuite) TestCloseSessionUnknownToken(c *C) {
+	sr := NewSearcher().(*searcher)
+	err := sr.CloseSession("no-such-token")
+	c.Assert(err, ErrorMatches, "target not found")
+}
+
+func (s *testSessionSuite) TestResumeReopensCursorFromNewStart(c *C) {
+	sr := NewSearcher().(*searcher)
+	dir := c.MkDir()
+
+	_, token, err := sr.SearchWithOptions(SearchOptions{Dir: dir})
+	c.Assert(err, IsNil)
+
+	resumed, err := sr.Resume(token, time.Now(), 0)
+	c.Assert(err, IsNil)
+	c.Assert(resumed, Equals, sr.getSession(token).iter)
+
+	_, err = resumed.Next()
+	c.Assert(err, Equals, io.EOF)
+
+	c.Assert(sr.CloseSession(token), IsNil)
+}
+
+func (s *testSessionSuite) TestResumeUnknownToken(c *C) {
+	sr := NewSearcher().(*searcher)
+	_, err := sr.Resume("no-such-token", time.Now(), 0)
+	c.Assert(err, ErrorMatches, "target not found")
+}
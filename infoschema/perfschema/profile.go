@@ -36,6 +36,31 @@ import (
 
 const profileInterval = 5
 
+// profileKind identifies which runtime profile a query wants.
+type profileKind string
+
+// The set of profiles TIDB_HEAP_PROFILE/CLUSTER_PROFILE can return. cpu is the
+// only one that needs to be actively sampled over an interval; the rest are
+// point-in-time snapshots taken from runtime/pprof.Lookup.
+const (
+	profileKindCPU       profileKind = "cpu"
+	profileKindHeap      profileKind = "heap"
+	profileKindMutex     profileKind = "mutex"
+	profileKindBlock     profileKind = "block"
+	profileKindAllocs    profileKind = "allocs"
+	profileKindGoroutine profileKind = "goroutine"
+)
+
+// pprofLookupName maps a profileKind to the name the `/pprof/<name>` HTTP
+// endpoints use, which for every kind but cpu is also the runtime/pprof.Lookup
+// name; cpu has no Lookup profile and is sampled separately.
+func (k profileKind) pprofLookupName() string {
+	if k == profileKindCPU {
+		return "cpu"
+	}
+	return string(k)
+}
+
 type Node struct {
 	Name      string
 	Location  string
@@ -0,0 +1,190 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package perfschema
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/sessionctx"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/sqlexec"
+)
+
+// localProfile captures a single in-process snapshot for kind. cpu is
+// actively sampled for seconds; every other kind is a point-in-time
+// snapshot read straight from runtime/pprof.Lookup, so seconds is ignored
+// for those.
+func localProfile(kind profileKind, seconds int) (*profile.Profile, error) {
+	buffer := &bytes.Buffer{}
+	if kind == profileKindCPU {
+		if err := pprof.StartCPUProfile(buffer); err != nil {
+			return nil, errors.Trace(err)
+		}
+		time.Sleep(time.Duration(seconds) * time.Second)
+		pprof.StopCPUProfile()
+	} else {
+		p := pprof.Lookup(kind.pprofLookupName())
+		if p == nil {
+			return nil, errors.Errorf("cannot retrieve %s profile", kind)
+		}
+		if err := p.WriteTo(buffer, 0); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return profile.Parse(buffer)
+}
+
+// remoteProfile fetches one snapshot from a peer's `/pprof/<kind>` endpoint.
+func remoteProfile(statusAddr string, kind profileKind, seconds int) (*profile.Profile, error) {
+	url := fmt.Sprintf("http://%s/pprof/%s?seconds=%d", statusAddr, kind.pprofLookupName(), seconds)
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	return profile.Parse(resp.Body)
+}
+
+// negateSampleValues flips the sign of every sample value in p in place, so
+// merging it with a later snapshot via diffProfiles produces a delta
+// instead of a sum.
+func negateSampleValues(p *profile.Profile) {
+	for _, s := range p.Sample {
+		for i := range s.Value {
+			s.Value[i] = -s.Value[i]
+		}
+	}
+}
+
+// diffProfiles returns the delta profile between two snapshots of the same
+// kind: before's sample values are negated, then merged with after via
+// profile.Merge, which both combines matching call stacks and accumulates
+// their (now signed) values.
+func diffProfiles(before, after *profile.Profile) (*profile.Profile, error) {
+	negateSampleValues(before)
+	merged, err := profile.Merge([]*profile.Profile{before, after})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return merged, nil
+}
+
+// tidbProfileGraph is the generalized, multi-kind replacement for
+// cpuProfileGraph: it captures one (or, in diff mode, two) in-process
+// snapshots of kind and renders them through the shared profileToDatums
+// tree renderer.
+func tidbProfileGraph(kind profileKind, seconds int, diff bool) ([][]types.Datum, error) {
+	first, err := localProfile(kind, seconds)
+	if err != nil {
+		return nil, err
+	}
+	if !diff {
+		return profileToDatums(first)
+	}
+	second, err := localProfile(kind, seconds)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := diffProfiles(first, second)
+	if err != nil {
+		return nil, err
+	}
+	return profileToDatums(merged)
+}
+
+// clusterProfileGraph is the generalized, multi-kind replacement for
+// tikvCpuProfileGraph: it fans the same capture out to every tikv node
+// (looked up the same way) and, in diff mode, takes two snapshots per node
+// before merging.
+//
+// TODO: use cluster info to get all node kinds, not just tikv.
+func clusterProfileGraph(ctx sessionctx.Context, kind profileKind, seconds int, diff bool) ([][]types.Datum, error) {
+	exec, ok := ctx.(sqlexec.RestrictedSQLExecutor)
+	if !ok {
+		return nil, errors.New("session does not support restricted SQL execution")
+	}
+	sql := "SELECT name, address, status_address FROM INFORMATION_SCHEMA.TIDB_CLUSTER_INFO WHERE type='tikv'"
+	rows, _, err := exec.ExecRestrictedSQL(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		rows [][]types.Datum
+		err  error
+	}
+
+	var finalRows [][]types.Datum
+	wg := sync.WaitGroup{}
+	ch := make(chan result, len(rows))
+	for _, row := range rows {
+		name := row.GetString(0)
+		address := row.GetString(1)
+		statusAddr := row.GetString(2)
+		if len(statusAddr) == 0 {
+			ctx.GetSessionVars().StmtCtx.AppendWarning(errors.Errorf("tikv node %s(%s) does not contain status address", name, address))
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			first, err := remoteProfile(statusAddr, kind, seconds)
+			if err != nil {
+				ch <- result{err: err}
+				return
+			}
+			p := first
+			if diff {
+				second, err := remoteProfile(statusAddr, kind, seconds)
+				if err != nil {
+					ch <- result{err: err}
+					return
+				}
+				p, err = diffProfiles(first, second)
+				if err != nil {
+					ch <- result{err: err}
+					return
+				}
+			}
+			rows, err := profileToDatums(p)
+			if err != nil {
+				ch <- result{err: err}
+				return
+			}
+			for i := range rows {
+				rows[i] = append(types.MakeDatums(name, address), rows[i]...)
+			}
+			ch <- result{rows: rows}
+		}()
+	}
+
+	wg.Wait()
+	close(ch)
+	for result := range ch {
+		if result.err != nil {
+			ctx.GetSessionVars().StmtCtx.AppendWarning(result.err)
+			continue
+		}
+		finalRows = append(finalRows, result.rows...)
+	}
+	return finalRows, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package perfschema
+
+import (
+	"testing"
+
+	"github.com/google/pprof/profile"
+	. "github.com/pingcap/check"
+)
+
+func TestT(t *testing.T) {
+	TestingT(t)
+}
+
+type testProfileKindSuite struct{}
+
+var _ = Suite(&testProfileKindSuite{})
+
+func newSampleProfile(fnName string, value int64) *profile.Profile {
+	fn := &profile.Function{ID: 1, Name: fnName}
+	loc := &profile.Location{ID: 1, Line: []profile.Line{{Function: fn}}}
+	return &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}},
+		PeriodType: &profile.ValueType{Type: "samples", Unit: "count"},
+		Sample:     []*profile.Sample{{Location: []*profile.Location{loc}, Value: []int64{value}}},
+		Function:   []*profile.Function{fn},
+		Location:   []*profile.Location{loc},
+	}
+}
+
+func (s *testProfileKindSuite) TestNegateSampleValues(c *C) {
+	p := newSampleProfile("foo", 10)
+	negateSampleValues(p)
+	c.Assert(p.Sample[0].Value[0], Equals, int64(-10))
+}
+
+func (s *testProfileKindSuite) TestDiffProfiles(c *C) {
+	before := newSampleProfile("foo", 10)
+	after := newSampleProfile("foo", 30)
+	merged, err := diffProfiles(before, after)
+	c.Assert(err, IsNil)
+	c.Assert(merged.Sample, HasLen, 1)
+	c.Assert(merged.Sample[0].Value[0], Equals, int64(20))
+}
+
+func (s *testProfileKindSuite) TestPprofLookupName(c *C) {
+	c.Assert(profileKindCPU.pprofLookupName(), Equals, "cpu")
+	c.Assert(profileKindHeap.pprofLookupName(), Equals, "heap")
+	c.Assert(profileKindGoroutine.pprofLookupName(), Equals, "goroutine")
+}